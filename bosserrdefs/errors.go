@@ -0,0 +1,108 @@
+// Package bosserrdefs defines boss's error classification, following the
+// same pattern as moby's api/errdefs: a small set of marker interfaces that
+// wrap an underlying error, plus Is* helpers that walk a pkg/errors.Causer
+// chain to find one. Callers key retry logic off these instead of sentinel
+// values or string matching on error text.
+package bosserrdefs
+
+// ErrNotFound is implemented by errors indicating a requested resource
+// (container, task, service) doesn't exist.
+type ErrNotFound interface {
+	NotFound() bool
+}
+
+// ErrInvalidParameter is implemented by errors indicating the caller
+// passed a bad argument (missing id, malformed config, ...).
+type ErrInvalidParameter interface {
+	InvalidParameter() bool
+}
+
+// ErrUnavailable is implemented by errors indicating a dependency
+// (containerd, the register backend, a CNI plugin) is transiently
+// unreachable and the operation can be retried.
+type ErrUnavailable interface {
+	Unavailable() bool
+}
+
+// ErrConflict is implemented by errors indicating the operation can't
+// proceed because of existing state (interface/route already present,
+// service already registered).
+type ErrConflict interface {
+	Conflict() bool
+}
+
+// ErrForbidden is implemented by errors indicating the operation isn't
+// permitted (apparmor profile rejected, maintenance mode denied).
+type ErrForbidden interface {
+	Forbidden() bool
+}
+
+// ErrSystem is implemented by errors indicating an unexpected failure in
+// boss itself or one of its dependencies that isn't any of the above and
+// isn't safe to retry.
+type ErrSystem interface {
+	System() bool
+}
+
+// causer is satisfied by github.com/pkg/errors-wrapped errors.
+type causer interface {
+	Cause() error
+}
+
+// walk reports whether match returns true for err or any error in its
+// Cause() chain.
+func walk(err error, match func(error) bool) bool {
+	for err != nil {
+		if match(err) {
+			return true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+func IsNotFound(err error) bool {
+	return walk(err, func(err error) bool {
+		e, ok := err.(ErrNotFound)
+		return ok && e.NotFound()
+	})
+}
+
+func IsInvalidParameter(err error) bool {
+	return walk(err, func(err error) bool {
+		e, ok := err.(ErrInvalidParameter)
+		return ok && e.InvalidParameter()
+	})
+}
+
+func IsUnavailable(err error) bool {
+	return walk(err, func(err error) bool {
+		e, ok := err.(ErrUnavailable)
+		return ok && e.Unavailable()
+	})
+}
+
+func IsConflict(err error) bool {
+	return walk(err, func(err error) bool {
+		e, ok := err.(ErrConflict)
+		return ok && e.Conflict()
+	})
+}
+
+func IsForbidden(err error) bool {
+	return walk(err, func(err error) bool {
+		e, ok := err.(ErrForbidden)
+		return ok && e.Forbidden()
+	})
+}
+
+func IsSystem(err error) bool {
+	return walk(err, func(err error) bool {
+		e, ok := err.(ErrSystem)
+		return ok && e.System()
+	})
+}
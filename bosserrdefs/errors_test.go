@@ -0,0 +1,60 @@
+package bosserrdefs
+
+import (
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestIsHelpers(t *testing.T) {
+	base := errors.New("boom")
+
+	cases := []struct {
+		name string
+		err  error
+		is   func(error) bool
+	}{
+		{"NotFound", NotFound(base), IsNotFound},
+		{"InvalidParameter", InvalidParameter(base), IsInvalidParameter},
+		{"Unavailable", Unavailable(base), IsUnavailable},
+		{"Conflict", Conflict(base), IsConflict},
+		{"Forbidden", Forbidden(base), IsForbidden},
+		{"System", System(base), IsSystem},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if !c.is(c.err) {
+				t.Fatalf("expected %s(%v) to be true", c.name, c.err)
+			}
+			for _, other := range cases {
+				if other.name == c.name {
+					continue
+				}
+				if other.is(c.err) {
+					t.Fatalf("expected %s(%v) to be false for a %s error", other.name, c.err, c.name)
+				}
+			}
+		})
+	}
+}
+
+func TestIsHelpersWalkCauseChain(t *testing.T) {
+	err := pkgerrors.Wrap(Unavailable(errors.New("connection refused")), "dial consul")
+	if !IsUnavailable(err) {
+		t.Fatalf("expected IsUnavailable to walk the Cause() chain and find the wrapped error")
+	}
+	if IsNotFound(err) {
+		t.Fatalf("expected IsNotFound to be false")
+	}
+}
+
+func TestIsHelpersNilErr(t *testing.T) {
+	if IsNotFound(nil) || IsUnavailable(nil) {
+		t.Fatalf("expected Is* helpers to report false for a nil error")
+	}
+	if NotFound(nil) != nil {
+		t.Fatalf("expected wrapping a nil error to return nil")
+	}
+}
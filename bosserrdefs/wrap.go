@@ -0,0 +1,85 @@
+package bosserrdefs
+
+// Each wrapper embeds the original error so Error() still works and Cause()
+// returns it, and adds the one marker method callers switch on. The
+// wrappers have no Unwrap method.
+
+type notFoundError struct{ error }
+
+func (notFoundError) NotFound() bool { return true }
+func (e notFoundError) Cause() error { return e.error }
+
+// NotFound wraps err so bosserrdefs.IsNotFound(err) reports true.
+func NotFound(err error) error {
+	if err == nil {
+		return nil
+	}
+	return notFoundError{err}
+}
+
+type invalidParameterError struct{ error }
+
+func (invalidParameterError) InvalidParameter() bool { return true }
+func (e invalidParameterError) Cause() error         { return e.error }
+
+// InvalidParameter wraps err so bosserrdefs.IsInvalidParameter(err) reports
+// true.
+func InvalidParameter(err error) error {
+	if err == nil {
+		return nil
+	}
+	return invalidParameterError{err}
+}
+
+type unavailableError struct{ error }
+
+func (unavailableError) Unavailable() bool { return true }
+func (e unavailableError) Cause() error    { return e.error }
+
+// Unavailable wraps err so bosserrdefs.IsUnavailable(err) reports true,
+// meaning the caller can reasonably retry.
+func Unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return unavailableError{err}
+}
+
+type conflictError struct{ error }
+
+func (conflictError) Conflict() bool { return true }
+func (e conflictError) Cause() error { return e.error }
+
+// Conflict wraps err so bosserrdefs.IsConflict(err) reports true.
+func Conflict(err error) error {
+	if err == nil {
+		return nil
+	}
+	return conflictError{err}
+}
+
+type forbiddenError struct{ error }
+
+func (forbiddenError) Forbidden() bool { return true }
+func (e forbiddenError) Cause() error  { return e.error }
+
+// Forbidden wraps err so bosserrdefs.IsForbidden(err) reports true.
+func Forbidden(err error) error {
+	if err == nil {
+		return nil
+	}
+	return forbiddenError{err}
+}
+
+type systemError struct{ error }
+
+func (systemError) System() bool   { return true }
+func (e systemError) Cause() error { return e.error }
+
+// System wraps err so bosserrdefs.IsSystem(err) reports true.
+func System(err error) error {
+	if err == nil {
+		return nil
+	}
+	return systemError{err}
+}
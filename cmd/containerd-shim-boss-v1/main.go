@@ -0,0 +1,20 @@
+// Command containerd-shim-boss-v1 is the containerd shim v2 binary for the
+// io.containerd.boss.v1 runtime. containerd execs this binary directly for
+// every task instead of boss running as a child of a systemd unit, so the
+// per-container networking, apparmor, service registration, and
+// maintenance handling that used to live in `boss systemd exec-start-*`
+// now lives here, driven by the task API, with no client reconnect loop
+// in between. `boss systemd
+// exec-start` still runs as the systemd unit's ExecStart, and is still the
+// process that forwards SIGTERM/SIGINT from systemd into the task.
+package main
+
+import (
+	shimv2 "github.com/containerd/containerd/runtime/v2/shim"
+
+	"github.com/crosbymichael/boss/shim"
+)
+
+func main() {
+	shimv2.Run(shim.RuntimeName, shim.New)
+}
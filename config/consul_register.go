@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/crosbymichael/boss/bosserrdefs"
+	"github.com/crosbymichael/boss/metrics"
+)
+
+// wrapConsul classifies a Consul agent API error as unavailable: every
+// call here goes over the local HTTP API, so a failure almost always means
+// the agent is down or unreachable and the caller can retry.
+func wrapConsul(err error) error {
+	if err == nil {
+		return nil
+	}
+	return bosserrdefs.Unavailable(err)
+}
+
+// ConsulRegister implements Register on top of the Consul agent API. It is
+// the default backend and the one boss has always used, just no longer
+// hard-coded into RegisterService itself.
+type ConsulRegister struct {
+	client *api.Client
+}
+
+// NewConsulRegister dials the local Consul agent using its default
+// configuration (CONSUL_HTTP_ADDR and friends).
+func NewConsulRegister() (*ConsulRegister, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &ConsulRegister{client: client}, nil
+}
+
+func (r *ConsulRegister) Register(id, name, ip string, srv Service) error {
+	return wrapConsul(r.client.Agent().ServiceRegister(&api.AgentServiceRegistration{
+		ID:      fmt.Sprintf("%s-%s", name, id),
+		Name:    name,
+		Tags:    srv.Tags,
+		Port:    srv.Port,
+		Address: ip,
+		Check:   consulCheck(srv.Health),
+	}))
+}
+
+// consulCheck translates a boss HealthCheck into the equivalent Consul
+// agent check, so upstream proxies honor the same HTTP/TCP probe boss uses
+// to drive maintenance mode. Command checks have no Consul equivalent here:
+// the Consul agent would run them on the host, whereas boss always runs a
+// Command check inside the task itself (see shim.service.execInTask), so
+// only the health package ever executes them.
+func consulCheck(hc *HealthCheck) *api.AgentServiceCheck {
+	if hc == nil {
+		return nil
+	}
+	check := &api.AgentServiceCheck{
+		Interval: hc.Interval.String(),
+		Timeout:  hc.Timeout.String(),
+	}
+	switch {
+	case hc.HTTP != "":
+		check.HTTP = hc.HTTP
+	case hc.TCP != "":
+		check.TCP = hc.TCP
+	default:
+		return nil
+	}
+	return check
+}
+
+func (r *ConsulRegister) Deregister(id string) error {
+	return wrapConsul(r.client.Agent().ServiceDeregister(id))
+}
+
+func (r *ConsulRegister) EnableMaintainance(id, reason string) error {
+	metrics.Record(metrics.MetricMaintenanceToggles, map[string]string{"action": "enable"})
+	return wrapConsul(r.client.Agent().EnableServiceMaintenance(id, reason))
+}
+
+func (r *ConsulRegister) DisableMaintainance(id string) error {
+	metrics.Record(metrics.MetricMaintenanceToggles, map[string]string{"action": "disable"})
+	return wrapConsul(r.client.Agent().DisableServiceMaintenance(id))
+}
+
+func (r *ConsulRegister) Checks(id string) ([]Check, error) {
+	checks, err := r.client.Agent().Checks()
+	if err != nil {
+		return nil, wrapConsul(err)
+	}
+	// Register gives each service the id "<name>-<id>", so a check's
+	// ServiceID never equals the bare task id on its own; match the suffix
+	// Register actually produces instead.
+	suffix := "-" + id
+	var out []Check
+	for _, c := range checks {
+		if !strings.HasSuffix(c.ServiceID, suffix) {
+			continue
+		}
+		out = append(out, Check{
+			Name:   c.ServiceName,
+			Status: c.Status,
+			Output: c.Output,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// HealthCheck describes how to probe a registered service, following the
+// same shape as podman's libpod/healthcheck: exactly one of Command, HTTP,
+// or TCP is set. It's attached to a Service and driven by the health
+// package for the life of the task, flipping maintenance mode after
+// Retries consecutive failures or on recovery.
+type HealthCheck struct {
+	Command     []string
+	HTTP        string
+	TCP         string
+	Interval    time.Duration
+	Timeout     time.Duration
+	Retries     int
+	StartPeriod time.Duration
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/crosbymichael/boss/bosserrdefs"
+	"github.com/crosbymichael/boss/metrics"
+)
+
+// wrapNats classifies a JetStream KV failure as unavailable, the same way
+// wrapConsul classifies a Consul agent failure: every call here goes over
+// a NATS connection, so a failure almost always means the server is down
+// or unreachable and the caller can retry. Without this, Register is only
+// backend-agnostic in name — callers keying retry/readiness logic off
+// bosserrdefs.IsUnavailable would get working classification against
+// Consul but never against NATS.
+func wrapNats(err error) error {
+	if err == nil {
+		return nil
+	}
+	return bosserrdefs.Unavailable(err)
+}
+
+const (
+	natsServiceBucket  = "services"
+	natsServiceEvents  = "services.events"
+	natsHeartbeatEvery = 10 * time.Second
+)
+
+// natsRecord is the JSON document stored for every registered service under
+// services/<name>/<id>. TTL expiry on the bucket is what actually drops a
+// stale entry; a per-service heartbeat goroutine re-Puts it every
+// natsHeartbeatEvery to keep a live service's TTL from ever elapsing. The
+// watcher goroutine republishes delete and purge operations (such as
+// Deregister's) as services.events messages; an entry that ages out under
+// the bucket TTL leaves no delete marker and is not republished.
+type natsRecord struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	IP            string    `json:"ip"`
+	Port          int       `json:"port"`
+	Tags          []string  `json:"tags,omitempty"`
+	Maintainance  bool      `json:"maintainance"`
+	Reason        string    `json:"reason,omitempty"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+// NatsRegister implements Register on a NATS JetStream KV bucket instead of
+// Consul, for deployments that already run NATS as their service mesh
+// backbone.
+type NatsRegister struct {
+	js  nats.JetStreamContext
+	kv  nats.KeyValue
+	nc  *nats.Conn
+	ttl time.Duration
+
+	mu         sync.Mutex
+	heartbeats map[string]chan struct{}
+
+	stop chan struct{}
+}
+
+// NewNatsRegister connects to the given NATS server and ensures the
+// services KV bucket exists, creating it with the given entry TTL if not.
+func NewNatsRegister(url string, ttl time.Duration) (*NatsRegister, error) {
+	nc, err := nats.Connect(url)
+	if err != nil {
+		return nil, wrapNats(err)
+	}
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, wrapNats(err)
+	}
+	kv, err := js.KeyValue(natsServiceBucket)
+	if err != nil {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: natsServiceBucket,
+			TTL:    ttl,
+		})
+		if err != nil {
+			nc.Close()
+			return nil, wrapNats(err)
+		}
+	}
+	r := &NatsRegister{
+		js:         js,
+		kv:         kv,
+		nc:         nc,
+		ttl:        ttl,
+		heartbeats: map[string]chan struct{}{},
+		stop:       make(chan struct{}),
+	}
+	go r.watch()
+	return r, nil
+}
+
+func natsKey(name, id string) string {
+	return fmt.Sprintf("services/%s/%s", name, id)
+}
+
+func (r *NatsRegister) Register(id, name, ip string, srv Service) error {
+	rec := natsRecord{
+		ID:            id,
+		Name:          name,
+		IP:            ip,
+		Port:          srv.Port,
+		Tags:          srv.Tags,
+		LastHeartbeat: time.Now(),
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	key := natsKey(name, id)
+	if _, err := r.kv.Put(key, data); err != nil {
+		return wrapNats(err)
+	}
+	r.startHeartbeat(key)
+	return nil
+}
+
+// startHeartbeat periodically re-Puts key's record so its TTL never
+// elapses while the service is still registered, until Deregister (or
+// Close) stops it. A single Put at Register time isn't enough: the bucket
+// expires entries on a fixed TTL with no renewal of its own.
+func (r *NatsRegister) startHeartbeat(key string) {
+	r.mu.Lock()
+	if _, ok := r.heartbeats[key]; ok {
+		r.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	r.heartbeats[key] = stop
+	r.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(natsHeartbeatEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if err := r.refresh(key); err != nil {
+					logrus.WithError(err).WithField("key", key).Debug("refresh service heartbeat")
+				}
+			}
+		}
+	}()
+}
+
+// refresh re-Puts key's current record unchanged except for
+// LastHeartbeat, so a concurrent EnableMaintainance/DisableMaintainance
+// isn't clobbered by a stale copy of the record.
+func (r *NatsRegister) refresh(key string) error {
+	entry, err := r.kv.Get(key)
+	if err != nil {
+		return err
+	}
+	var rec natsRecord
+	if err := json.Unmarshal(entry.Value(), &rec); err != nil {
+		return err
+	}
+	rec.LastHeartbeat = time.Now()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = r.kv.Put(key, data)
+	return err
+}
+
+// stopHeartbeat stops key's heartbeat goroutine, called once its record is
+// deleted so it doesn't immediately re-Put a record Deregister just
+// removed.
+func (r *NatsRegister) stopHeartbeat(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stop, ok := r.heartbeats[key]; ok {
+		close(stop)
+		delete(r.heartbeats, key)
+	}
+}
+
+func (r *NatsRegister) Deregister(id string) error {
+	keys, err := r.kv.Keys()
+	if err != nil {
+		return wrapNats(err)
+	}
+	for _, k := range keys {
+		entry, err := r.kv.Get(k)
+		if err != nil {
+			continue
+		}
+		var rec natsRecord
+		if err := json.Unmarshal(entry.Value(), &rec); err != nil {
+			continue
+		}
+		if rec.ID == id {
+			if err := r.kv.Delete(k); err != nil {
+				return wrapNats(err)
+			}
+			r.stopHeartbeat(k)
+		}
+	}
+	return nil
+}
+
+func (r *NatsRegister) EnableMaintainance(id, reason string) error {
+	return r.setMaintainance(id, true, reason)
+}
+
+func (r *NatsRegister) DisableMaintainance(id string) error {
+	return r.setMaintainance(id, false, "")
+}
+
+func (r *NatsRegister) setMaintainance(id string, on bool, reason string) error {
+	action := "disable"
+	if on {
+		action = "enable"
+	}
+	metrics.Record(metrics.MetricMaintenanceToggles, map[string]string{"action": action})
+	keys, err := r.kv.Keys()
+	if err != nil {
+		return wrapNats(err)
+	}
+	for _, k := range keys {
+		entry, err := r.kv.Get(k)
+		if err != nil {
+			continue
+		}
+		var rec natsRecord
+		if err := json.Unmarshal(entry.Value(), &rec); err != nil {
+			continue
+		}
+		if rec.ID != id {
+			continue
+		}
+		rec.Maintainance = on
+		rec.Reason = reason
+		rec.LastHeartbeat = time.Now()
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if _, err := r.kv.Put(k, data); err != nil {
+			return wrapNats(err)
+		}
+	}
+	return nil
+}
+
+func (r *NatsRegister) Checks(id string) ([]Check, error) {
+	keys, err := r.kv.Keys()
+	if err != nil {
+		return nil, wrapNats(err)
+	}
+	var out []Check
+	for _, k := range keys {
+		entry, err := r.kv.Get(k)
+		if err != nil {
+			continue
+		}
+		var rec natsRecord
+		if err := json.Unmarshal(entry.Value(), &rec); err != nil {
+			continue
+		}
+		if rec.ID != id {
+			continue
+		}
+		status := "passing"
+		if rec.Maintainance {
+			status = "maintenance"
+		}
+		out = append(out, Check{
+			Name:   rec.Name,
+			Status: status,
+			Output: rec.Reason,
+		})
+	}
+	return out, nil
+}
+
+// watch subscribes to the bucket's updates and republishes the key of
+// every delete or purge operation to services.events so other boss
+// processes (and the diagnostics endpoint) can react to a service dropping
+// out without polling the KV themselves. Entries that age out under the
+// bucket TTL produce no such operation and are not republished.
+func (r *NatsRegister) watch() {
+	watcher, err := r.kv.WatchAll()
+	if err != nil {
+		return
+	}
+	defer watcher.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case entry := <-watcher.Updates():
+			if entry == nil {
+				continue
+			}
+			if entry.Operation() == nats.KeyValueDelete || entry.Operation() == nats.KeyValuePurge {
+				r.nc.Publish(natsServiceEvents, []byte(entry.Key()))
+			}
+		}
+	}
+}
+
+// Close stops the watcher goroutine and closes the NATS connection.
+func (r *NatsRegister) Close() error {
+	close(r.stop)
+	r.nc.Close()
+	return nil
+}
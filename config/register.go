@@ -2,18 +2,50 @@ package config
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/containerd/containerd"
 	"github.com/crosbymichael/boss/util"
-	"github.com/hashicorp/consul/api"
 	"github.com/urfave/cli"
 )
 
+// Service describes a single named port a container exposes, to be
+// registered against whatever Register backend is configured. Health, if
+// set, is driven by the health package for the life of the task and
+// toggles maintenance mode on the registration.
+type Service struct {
+	Port   int
+	Tags   []string
+	Health *HealthCheck
+}
+
+// Check is a single health check result reported by a Register backend.
+type Check struct {
+	Name   string
+	Status string
+	Output string
+}
+
+// Register is implemented by service-discovery backends. boss ships a
+// Consul implementation (ConsulRegister) and a NATS JetStream KV
+// implementation (NatsRegister); which one is active comes from the
+// `register` section of the boss config file. Callers such as the shim's
+// Create/Start/Delete, health.Run, and `boss ps` only ever talk to this
+// interface, so they stay backend-agnostic.
+type Register interface {
+	Register(id, name, ip string, srv Service) error
+	Deregister(id string) error
+	EnableMaintainance(id, reason string) error
+	DisableMaintainance(id string) error
+	Checks(id string) ([]Check, error)
+}
+
+// RegisterService is a task-level declaration of a single service to
+// register, as configured on a container.
 type RegisterService struct {
 	ID     string
 	Port   int
 	Tags   []string
+	Health *HealthCheck
 	Config *Config
 }
 
@@ -26,25 +58,13 @@ func (s *RegisterService) Run(ctx context.Context, client *containerd.Client, cl
 	if err != nil {
 		return err
 	}
-	consul, err := api.NewClient(api.DefaultConfig())
-	if err != nil {
-		return err
-	}
-	reg := &api.AgentServiceRegistration{
-		ID:      fmt.Sprintf("%s-%s", s.ID, s.Config.ID),
-		Name:    s.ID,
-		Tags:    s.Tags,
-		Port:    s.Port,
-		Address: ip,
-	}
-	return consul.Agent().ServiceRegister(reg)
+	return s.Config.GetRegister().Register(s.Config.ID, s.ID, ip, Service{
+		Port:   s.Port,
+		Tags:   s.Tags,
+		Health: s.Health,
+	})
 }
 
 func (s *RegisterService) Remove(ctx context.Context, client *containerd.Client, clix *cli.Context) error {
-	consul, err := api.NewClient(api.DefaultConfig())
-	if err != nil {
-		return nil
-	}
-	consul.Agent().ServiceDeregister(s.ID)
-	return nil
+	return s.Config.GetRegister().Deregister(s.Config.ID)
 }
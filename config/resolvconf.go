@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteResolvConf writes a /etc/boss/resolv.conf listing nameservers, one
+// per line. It used to run once in the systemd exec-start-pre hook, before
+// every container start; the shim's Create is the closest equivalent now
+// that networking setup lives there instead.
+func WriteResolvConf(nameservers []string) error {
+	f, err := os.Create(filepath.Join(Root, "resolv.conf"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, ns := range nameservers {
+		if _, err := f.WriteString(fmt.Sprintf("nameserver %s\n", ns)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/crosbymichael/boss/metrics"
+	"github.com/crosbymichael/boss/system"
+)
+
+var diagnosticsCommand = cli.Command{
+	Name:  "diagnostics",
+	Usage: "serve healthz/readyz/metrics/pprof for monitoring boss itself",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "addr",
+			Usage: "address to serve diagnostics on",
+			Value: "127.0.0.1:9090",
+		},
+	},
+	Action: func(clix *cli.Context) error {
+		if err := system.Ready(cfg); err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", handleHealthz)
+		mux.HandleFunc("/readyz", handleReadyz)
+		mux.Handle("/metrics", metricsHandler())
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+		addr := clix.String("addr")
+		logrus.WithField("addr", addr).Info("serving boss diagnostics")
+		return http.ListenAndServe(addr, mux)
+	},
+}
+
+// metricsHandler serves a dedicated registry holding only metrics.Collector
+// instead of the global default registry promhttp.Handler() reads: boss's
+// counters are incremented by shim and CLI processes that never share that
+// registry with this one, so it would always read zero. Collector reads
+// the shared events log itself at scrape time instead.
+func metricsHandler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics.Collector{})
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz verifies boss can actually do its job: containerd is
+// serving and, if a register backend is configured, it's reachable too.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx := cfg.Context()
+	serving, err := cfg.Client().IsServing(ctx)
+	if err != nil || !serving {
+		http.Error(w, fmt.Sprintf("containerd not serving: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	if reg := cfg.GetRegister(); reg != nil {
+		if _, err := reg.Checks(""); err != nil {
+			http.Error(w, fmt.Sprintf("register backend unreachable: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ready")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/urfave/cli"
+)
+
+var generateCommand = cli.Command{
+	Name:  "generate",
+	Usage: "generate configuration for containers",
+	Subcommands: []cli.Command{
+		generateSystemdCommand,
+	},
+}
+
+var generateSystemdCommand = cli.Command{
+	Name:  "systemd",
+	Usage: "generate a systemd unit file for an existing container",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "files",
+			Usage: "write the unit into /etc/systemd/system instead of printing it",
+		},
+		cli.StringFlag{
+			Name:  "restart-policy",
+			Usage: "systemd Restart= value",
+			Value: "on-failure",
+		},
+		cli.BoolFlag{
+			Name:  "new",
+			Usage: "template the unit on %i instead of a fixed container id",
+		},
+		cli.StringSliceFlag{
+			Name:  "after",
+			Usage: "additional unit names to depend on, beyond network-online.target",
+		},
+	},
+	Action: func(clix *cli.Context) error {
+		id := clix.Args().First()
+		if id == "" {
+			return errIDRequired
+		}
+		ctx := cfg.Context()
+		container, err := cfg.Client().LoadContainer(ctx, id)
+		if err != nil {
+			return err
+		}
+		c, err := cfg.ContainerConfig(id)
+		if err != nil {
+			return err
+		}
+		unit := unitSpec{
+			ID:            container.ID(),
+			RestartPolicy: clix.String("restart-policy"),
+			New:           clix.Bool("new"),
+			After:         append([]string{"network-online.target"}, clix.StringSlice("after")...),
+		}
+		if cfg.GetRegister() != nil && len(c.Services) > 0 {
+			unit.After = append(unit.After, "consul.service")
+		}
+		for name := range c.Services {
+			unit.WantedBy = append(unit.WantedBy, name+".target")
+		}
+
+		var w io.Writer = os.Stdout
+		if clix.Bool("files") {
+			name := unit.ID
+			if unit.New {
+				name = "boss@"
+			}
+			path := filepath.Join("/etc/systemd/system", name+".service")
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return unitTemplate.Execute(w, unit)
+	},
+}
+
+type unitSpec struct {
+	ID            string
+	RestartPolicy string
+	New           bool
+	After         []string
+	WantedBy      []string
+}
+
+func (u unitSpec) Instance() string {
+	if u.New {
+		return "%i"
+	}
+	return u.ID
+}
+
+// The shim now owns networking, apparmor, and service registration (see
+// the shim package), so the unit only needs to start and supervise the
+// task itself instead of the old exec-start-pre/exec-start/exec-start-post
+// split.
+var unitTemplate = template.Must(template.New("systemd-unit").Parse(`[Unit]
+Description=boss container {{.ID}}
+{{range .After}}After={{.}}
+{{end}}
+[Service]
+ExecStart=/usr/local/bin/boss systemd exec-start {{.Instance}}
+Restart={{.RestartPolicy}}
+TimeoutStopSec=30
+KillMode=mixed
+Delegate=yes
+
+[Install]
+{{range .WantedBy}}WantedBy={{.}}
+{{end}}
+`))
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnitTemplateFixedContainer(t *testing.T) {
+	unit := unitSpec{
+		ID:            "myapp",
+		RestartPolicy: "on-failure",
+		After:         []string{"network-online.target", "consul.service"},
+		WantedBy:      []string{"web.target"},
+	}
+
+	var buf bytes.Buffer
+	if err := unitTemplate.Execute(&buf, unit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/boss systemd exec-start myapp",
+		"Restart=on-failure",
+		"After=network-online.target",
+		"After=consul.service",
+		"WantedBy=web.target",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected rendered unit to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestUnitTemplateTemplatedInstance(t *testing.T) {
+	unit := unitSpec{
+		ID:  "myapp",
+		New: true,
+	}
+
+	var buf bytes.Buffer
+	if err := unitTemplate.Execute(&buf, unit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, "ExecStart=/usr/local/bin/boss systemd exec-start %i") {
+		t.Fatalf("expected a New unit to template ExecStart on %%i, got:\n%s", out)
+	}
+	if strings.Contains(out, "exec-start myapp") {
+		t.Fatalf("expected a New unit not to hardcode the container id, got:\n%s", out)
+	}
+}
+
+func TestUnitSpecInstance(t *testing.T) {
+	fixed := unitSpec{ID: "myapp"}
+	if got := fixed.Instance(); got != "myapp" {
+		t.Fatalf("expected Instance() to return the container id, got %q", got)
+	}
+
+	templated := unitSpec{ID: "myapp", New: true}
+	if got := templated.Instance(); got != "%i" {
+		t.Fatalf("expected a New unitSpec's Instance() to return %%i, got %q", got)
+	}
+}
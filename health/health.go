@@ -0,0 +1,146 @@
+// Package health runs container healthchecks and flips service maintenance
+// mode on transitions, the way podman's libpod/healthcheck drives its own
+// container state. Checks used to only fire once, on task exit, in the
+// systemd exec-start-post hook; this package runs them continuously for the
+// life of the task instead.
+//
+// Health state itself isn't kept here: each task's checks run inside its
+// own long-lived io.containerd.boss.v1 shim process, so a package-level
+// variable would only ever be visible to that one process, never to a
+// separate `boss ps` or `boss diagnostics` invocation. Run reports state
+// transitions through the configured Register backend
+// (EnableMaintainance/DisableMaintainance) instead, the same cross-process
+// channel every other consumer of task health already goes through.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/crosbymichael/boss/config"
+)
+
+// State is the health the last completed check observed.
+type State string
+
+const (
+	StateStarting  State = "starting"
+	StateHealthy   State = "healthy"
+	StateUnhealthy State = "unhealthy"
+)
+
+// Execer runs cmd inside the task being checked, the same way containerd's
+// task.Exec runs a process in the task's own namespaces, and returns an
+// error if it exits non-zero. A Command healthcheck has no meaning without
+// one: running it on the shim's own host namespaces instead of the task's
+// would check the wrong filesystem/network entirely.
+type Execer func(ctx context.Context, cmd []string) error
+
+// Run drives hc against id/name until ctx is canceled, flipping the
+// service's registration into and out of maintenance mode as its health
+// changes. It's meant to be started as its own goroutine per service, once
+// a task's task.Start succeeds. exec runs hc.Command checks inside the
+// task; it may be nil if hc has no Command check.
+func Run(ctx context.Context, reg config.Register, id, name string, hc *config.HealthCheck, exec Execer) {
+	if hc.StartPeriod > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(hc.StartPeriod):
+		}
+	}
+
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	state := StateStarting
+	var failures int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			state = transition(reg, id, name, hc, state, check(ctx, hc, exec), &failures)
+		}
+	}
+}
+
+// transition applies a single check result to the current state, calling
+// through to the Register backend on every edge into StateHealthy or
+// StateUnhealthy (including from StateStarting), and returns the (possibly
+// unchanged) new state.
+func transition(reg config.Register, id, name string, hc *config.HealthCheck, state State, err error, failures *int) State {
+	if err != nil {
+		*failures++
+		logrus.WithError(err).WithField("id", id).WithField("service", name).Debug("healthcheck failed")
+		if *failures >= hc.Retries && state != StateUnhealthy {
+			if merr := reg.EnableMaintainance(id, "healthcheck: "+err.Error()); merr != nil {
+				logrus.WithError(merr).Error("enable service maintenance")
+			}
+			return StateUnhealthy
+		}
+		return state
+	}
+	*failures = 0
+	if state != StateHealthy {
+		if merr := reg.DisableMaintainance(id); merr != nil {
+			logrus.WithError(merr).Error("disable service maintenance")
+		}
+	}
+	return StateHealthy
+}
+
+func check(ctx context.Context, hc *config.HealthCheck, exec Execer) error {
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case len(hc.Command) > 0:
+		if exec == nil {
+			return fmt.Errorf("healthcheck: command check configured with no way to exec into the task")
+		}
+		return exec(cctx, hc.Command)
+	case hc.HTTP != "":
+		req, err := http.NewRequestWithContext(cctx, http.MethodGet, hc.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return errStatus(resp.StatusCode)
+		}
+		return nil
+	case hc.TCP != "":
+		d := net.Dialer{}
+		conn, err := d.DialContext(cctx, "tcp", hc.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		return nil
+	}
+}
+
+type errStatus int
+
+func (e errStatus) Error() string {
+	return fmt.Sprintf("unhealthy status %d %s", int(e), http.StatusText(int(e)))
+}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crosbymichael/boss/config"
+)
+
+type fakeRegister struct {
+	enabled  int
+	disabled int
+}
+
+func (r *fakeRegister) Register(id, name, ip string, srv config.Service) error { return nil }
+func (r *fakeRegister) Deregister(id string) error                             { return nil }
+func (r *fakeRegister) Checks(id string) ([]config.Check, error)               { return nil, nil }
+
+func (r *fakeRegister) EnableMaintainance(id, reason string) error {
+	r.enabled++
+	return nil
+}
+
+func (r *fakeRegister) DisableMaintainance(id string) error {
+	r.disabled++
+	return nil
+}
+
+func TestTransitionHealthyStaysHealthy(t *testing.T) {
+	reg := &fakeRegister{}
+	hc := &config.HealthCheck{Retries: 3}
+	failures := 0
+
+	state := transition(reg, "id", "web", hc, StateHealthy, nil, &failures)
+	if state != StateHealthy {
+		t.Fatalf("expected StateHealthy, got %s", state)
+	}
+	if reg.disabled != 0 {
+		t.Fatalf("expected DisableMaintainance not called on an already-healthy service, got %d calls", reg.disabled)
+	}
+}
+
+func TestTransitionStartingToHealthyDisablesMaintenance(t *testing.T) {
+	reg := &fakeRegister{}
+	hc := &config.HealthCheck{Retries: 3}
+	failures := 0
+
+	state := transition(reg, "id", "web", hc, StateStarting, nil, &failures)
+	if state != StateHealthy {
+		t.Fatalf("expected StateHealthy, got %s", state)
+	}
+	if reg.disabled != 1 {
+		t.Fatalf("expected DisableMaintainance to be called once, got %d", reg.disabled)
+	}
+}
+
+func TestTransitionFailuresBelowRetriesStayHealthy(t *testing.T) {
+	reg := &fakeRegister{}
+	hc := &config.HealthCheck{Retries: 3}
+	failures := 0
+	err := errors.New("check failed")
+
+	state := StateHealthy
+	state = transition(reg, "id", "web", hc, state, err, &failures)
+	state = transition(reg, "id", "web", hc, state, err, &failures)
+	if state != StateHealthy {
+		t.Fatalf("expected state to stay healthy below hc.Retries, got %s", state)
+	}
+	if reg.enabled != 0 {
+		t.Fatalf("expected EnableMaintainance not yet called, got %d calls", reg.enabled)
+	}
+}
+
+func TestTransitionFailuresAtRetriesEnablesMaintenance(t *testing.T) {
+	reg := &fakeRegister{}
+	hc := &config.HealthCheck{Retries: 2}
+	failures := 0
+	err := errors.New("check failed")
+
+	state := StateHealthy
+	state = transition(reg, "id", "web", hc, state, err, &failures)
+	state = transition(reg, "id", "web", hc, state, err, &failures)
+	if state != StateUnhealthy {
+		t.Fatalf("expected StateUnhealthy once failures reach hc.Retries, got %s", state)
+	}
+	if reg.enabled != 1 {
+		t.Fatalf("expected EnableMaintainance to be called once, got %d", reg.enabled)
+	}
+
+	// a further failure while already unhealthy shouldn't toggle maintenance again
+	state = transition(reg, "id", "web", hc, state, err, &failures)
+	if reg.enabled != 1 {
+		t.Fatalf("expected EnableMaintainance to stay at 1 call while already unhealthy, got %d", reg.enabled)
+	}
+}
+
+func TestTransitionRecoveryResetsFailures(t *testing.T) {
+	reg := &fakeRegister{}
+	hc := &config.HealthCheck{Retries: 2}
+	failures := 0
+	err := errors.New("check failed")
+
+	state := StateHealthy
+	state = transition(reg, "id", "web", hc, state, err, &failures)
+	state = transition(reg, "id", "web", hc, state, nil, &failures)
+	if state != StateHealthy || failures != 0 {
+		t.Fatalf("expected a success to reset failures and report healthy, got state=%s failures=%d", state, failures)
+	}
+}
+
+func TestCheckCommandUsesExecer(t *testing.T) {
+	hc := &config.HealthCheck{Command: []string{"test", "-f", "/ready"}}
+
+	var gotCmd []string
+	exec := func(ctx context.Context, cmd []string) error {
+		gotCmd = cmd
+		return nil
+	}
+	if err := check(context.Background(), hc, exec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gotCmd) != 3 || gotCmd[0] != "test" {
+		t.Fatalf("expected the configured command to be passed to exec, got %v", gotCmd)
+	}
+}
+
+func TestCheckCommandWithoutExecerErrors(t *testing.T) {
+	hc := &config.HealthCheck{Command: []string{"test", "-f", "/ready"}}
+	if err := check(context.Background(), hc, nil); err == nil {
+		t.Fatalf("expected an error when no Execer is available for a Command check")
+	}
+}
+
+func TestCheckNoProbeConfiguredIsHealthy(t *testing.T) {
+	if err := check(context.Background(), &config.HealthCheck{}, nil); err != nil {
+		t.Fatalf("expected a HealthCheck with no probe configured to report healthy, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelOrder fixes the label schema for each metric Record is called with,
+// so Collector can turn a label map back into the ordered values
+// prometheus.NewDesc's variable labels expect.
+var labelOrder = map[string][]string{
+	MetricRouteOps:           {"op", "result"},
+	MetricMaintenanceToggles: {"action"},
+	MetricRestarts:           nil,
+}
+
+var descs = map[string]*prometheus.Desc{
+	MetricRouteOps: prometheus.NewDesc(
+		"boss_route_operations_total",
+		"Number of CNI network operations performed, by op (add, remove) and result (ok, error).",
+		labelOrder[MetricRouteOps], nil,
+	),
+	MetricMaintenanceToggles: prometheus.NewDesc(
+		"boss_register_maintenance_toggles_total",
+		"Number of maintenance mode toggles performed against the service registry, by action (enable, disable).",
+		labelOrder[MetricMaintenanceToggles], nil,
+	),
+	MetricRestarts: prometheus.NewDesc(
+		"boss_restarts_total",
+		"Number of times systemd exec-start found and killed a stale task before starting a new one.",
+		labelOrder[MetricRestarts], nil,
+	),
+}
+
+// Collector implements prometheus.Collector by reading the shared events
+// log at scrape time and tallying it up into the counters boss's
+// subsystems record through Record, regardless of which process wrote
+// them.
+type Collector struct{}
+
+func (Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range descs {
+		ch <- d
+	}
+}
+
+func (Collector) Collect(ch chan<- prometheus.Metric) {
+	counts, values := tally()
+
+	for metric, byKey := range counts {
+		desc := descs[metric]
+		for key, count := range byKey {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, count, values[metric][key]...)
+		}
+	}
+}
+
+// tally reads every event recorded so far and sums it up per metric and
+// label combination, reconstructing the ordered label values each
+// combination's "\x00"-joined key stands for. It's shared by Collect, which
+// turns the result into prometheus.Metrics, and compact (see metrics.go),
+// which folds the events log back down to one entry per combination.
+func tally() (counts map[string]map[string]float64, values map[string]map[string][]string) {
+	counts = map[string]map[string]float64{}
+	values = map[string]map[string][]string{}
+
+	forEachEvent(func(e event) {
+		order, ok := labelOrder[e.Metric]
+		if !ok {
+			return
+		}
+		labelValues := make([]string, len(order))
+		for i, k := range order {
+			labelValues[i] = e.Labels[k]
+		}
+		key := strings.Join(labelValues, "\x00")
+
+		if counts[e.Metric] == nil {
+			counts[e.Metric] = map[string]float64{}
+			values[e.Metric] = map[string][]string{}
+		}
+		counts[e.Metric][key] += e.count()
+		values[e.Metric][key] = labelValues
+	})
+
+	return counts, values
+}
+
+// forEachEvent calls fn with every event recorded so far, skipping any
+// line that fails to parse (e.g. a write torn by a crash mid-append).
+func forEachEvent(fn func(event)) {
+	f, err := os.Open(filepath.Join(Dir, eventsFile))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		fn(e)
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeEventsFile(t *testing.T, lines ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	Dir = dir
+	data := ""
+	for _, l := range lines {
+		data += l + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, eventsFile), []byte(data), 0644); err != nil {
+		t.Fatalf("write events file: %v", err)
+	}
+}
+
+func collectEvents(t *testing.T) []event {
+	t.Helper()
+	var got []event
+	forEachEvent(func(e event) {
+		got = append(got, e)
+	})
+	return got
+}
+
+func TestForEachEventSkipsCorruptLines(t *testing.T) {
+	writeEventsFile(t,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+		`not json at all`,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+	)
+
+	got := collectEvents(t)
+	if len(got) != 2 {
+		t.Fatalf("expected the corrupt line to be skipped and both valid lines kept, got %d events", len(got))
+	}
+}
+
+func TestForEachEventMissingFileIsEmpty(t *testing.T) {
+	Dir = t.TempDir()
+
+	if got := collectEvents(t); len(got) != 0 {
+		t.Fatalf("expected no events when events.log doesn't exist, got %d", len(got))
+	}
+}
+
+func TestTallyCountsRepeatedEventsForSameLabels(t *testing.T) {
+	writeEventsFile(t,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+	)
+
+	counts, values := tally()
+	key := "add\x00ok"
+	if got := counts[MetricRouteOps][key]; got != 3 {
+		t.Fatalf("expected 3 add/ok events to tally to 3, got %v", got)
+	}
+	if got := values[MetricRouteOps][key]; len(got) != 2 || got[0] != "add" || got[1] != "ok" {
+		t.Fatalf("expected ordered label values [add ok], got %v", got)
+	}
+}
+
+func TestTallyKeepsDistinctLabelCombinationsSeparate(t *testing.T) {
+	writeEventsFile(t,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"error"}}`,
+		`{"metric":"route_operations_total","labels":{"op":"remove","result":"ok"}}`,
+	)
+
+	counts, values := tally()
+	byKey := counts[MetricRouteOps]
+	if len(byKey) != 3 {
+		t.Fatalf("expected 3 distinct op/result combinations, got %d", len(byKey))
+	}
+	if got := values[MetricRouteOps]["add\x00error"]; len(got) != 2 || got[0] != "add" || got[1] != "error" {
+		t.Fatalf("expected [add error] label values, got %v", got)
+	}
+}
+
+func TestTallyHandlesUnlabeledMetric(t *testing.T) {
+	writeEventsFile(t,
+		`{"metric":"restarts_total"}`,
+		`{"metric":"restarts_total"}`,
+	)
+
+	counts, values := tally()
+	if got := counts[MetricRestarts][""]; got != 2 {
+		t.Fatalf("expected 2 restarts to tally to 2 under the empty key, got %v", got)
+	}
+	if got := values[MetricRestarts][""]; len(got) != 0 {
+		t.Fatalf("expected no label values for an unlabeled metric, got %v", got)
+	}
+}
+
+func TestTallyIgnoresUnknownMetric(t *testing.T) {
+	writeEventsFile(t, `{"metric":"not_a_real_metric"}`)
+
+	counts, _ := tally()
+	if len(counts) != 0 {
+		t.Fatalf("expected an unrecognized metric name to be ignored, got %v", counts)
+	}
+}
+
+func TestTallySumsCompactedEventCounts(t *testing.T) {
+	writeEventsFile(t,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"},"count":7}`,
+		`{"metric":"route_operations_total","labels":{"op":"add","result":"ok"}}`,
+	)
+
+	counts, _ := tally()
+	if got := counts[MetricRouteOps]["add\x00ok"]; got != 8 {
+		t.Fatalf("expected a compacted count of 7 plus a regular increment to tally to 8, got %v", got)
+	}
+}
@@ -0,0 +1,134 @@
+// Package metrics records the counters boss's subsystems (route, config,
+// systemd) increment and makes them available to `boss diagnostics`'s
+// /metrics endpoint.
+//
+// Those subsystems run inside per-task io.containerd.boss.v1 shim
+// processes and inside one-shot `boss` CLI invocations, never inside the
+// long-running `boss diagnostics` process itself, so a package-level
+// prometheus.CounterVec shared in memory would only ever be visible to
+// whichever process incremented it. Record appends each increment to a
+// shared events log on disk instead; Collector (see collector.go) tallies
+// that log back up into Prometheus counters at scrape time, regardless of
+// which process recorded the events. Record also compacts the log once it
+// grows past maxEventsFileSize, folding it down to one running total per
+// metric/label combination, so it doesn't grow forever.
+package metrics
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Dir is where the shared events log lives. It needs to be writable by
+// every shim process and every `boss` CLI invocation, and readable by
+// `boss diagnostics`, so it defaults next to boss's other runtime state.
+var Dir = "/run/boss/metrics"
+
+const eventsFile = "events.log"
+
+// maxEventsFileSize bounds how large the events log is allowed to grow
+// before Record compacts it. Without this, both disk usage and every
+// /metrics scrape's cost would grow unbounded over the life of a
+// long-running host.
+const maxEventsFileSize = 1 << 20 // 1MiB
+
+// Metric names. Each one has a fixed label schema declared in collector.go
+// alongside its prometheus.Desc.
+const (
+	MetricRouteOps           = "route_operations_total"
+	MetricMaintenanceToggles = "register_maintenance_toggles_total"
+	MetricRestarts           = "restarts_total"
+)
+
+// event is a single counter increment, or, once compact has folded the log
+// down, a running total for one metric/label combination. It's appended as
+// one JSON line so concurrent writers from different processes never
+// interleave a partial record: each line is well under the size Linux
+// guarantees an O_APPEND write is atomic at.
+type event struct {
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Count is how much this event increments its counter by. It's left
+	// unset (and omitted) for a regular Record call, which always means a
+	// single increment; compact sets it explicitly to the tally it folded
+	// a run of events into.
+	Count float64 `json:"count,omitempty"`
+}
+
+// count returns how much e increments its counter by, treating the zero
+// value Record leaves Count at as a single increment.
+func (e event) count() float64 {
+	if e.Count == 0 {
+		return 1
+	}
+	return e.Count
+}
+
+var mu sync.Mutex
+
+// Record appends a single increment of metric, labeled by labels, to the
+// shared events log. labels may be nil for a metric with no labels.
+func Record(metric string, labels map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return
+	}
+	path := filepath.Join(Dir, eventsFile)
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= maxEventsFileSize {
+		compact(path)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(event{Metric: metric, Labels: labels})
+	if err != nil {
+		return
+	}
+	f.Write(append(data, '\n'))
+}
+
+// compact folds every event recorded so far at path into a single
+// Count-carrying event per metric/label combination, the same tally
+// Collector would report at scrape time, bounding the events log's size
+// regardless of how long the host has been up. It writes the result to a
+// temporary file and renames it over path, so a reader never sees a
+// truncated file. Must be called with mu held.
+func compact(path string) {
+	counts, values := tally()
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(f)
+	for metric, byKey := range counts {
+		order := labelOrder[metric]
+		for key, count := range byKey {
+			var labels map[string]string
+			if len(order) > 0 {
+				labelValues := values[metric][key]
+				labels = make(map[string]string, len(order))
+				for i, k := range order {
+					labels[k] = labelValues[i]
+				}
+			}
+			if err := enc.Encode(event{Metric: metric, Labels: labels, Count: count}); err != nil {
+				f.Close()
+				return
+			}
+		}
+	}
+	if err := f.Close(); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open events file: %v", err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestRecordAppendsOneLinePerCall(t *testing.T) {
+	Dir = t.TempDir()
+
+	Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+	Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+
+	path := filepath.Join(Dir, eventsFile)
+	if got := countLines(t, path); got != 2 {
+		t.Fatalf("expected 2 appended lines, got %d", got)
+	}
+
+	counts, _ := tally()
+	if got := counts[MetricRouteOps]["add\x00ok"]; got != 2 {
+		t.Fatalf("expected both increments to tally to 2, got %v", got)
+	}
+}
+
+func TestCompactFoldsEventsToOneLinePerCombination(t *testing.T) {
+	Dir = t.TempDir()
+	path := filepath.Join(Dir, eventsFile)
+
+	Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+	Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+	Record(MetricRouteOps, map[string]string{"op": "remove", "result": "error"})
+	Record(MetricRestarts, nil)
+
+	compact(path)
+
+	if got := countLines(t, path); got != 3 {
+		t.Fatalf("expected compact to fold 4 events into 3 combinations, got %d lines", got)
+	}
+
+	counts, values := tally()
+	if got := counts[MetricRouteOps]["add\x00ok"]; got != 2 {
+		t.Fatalf("expected the compacted add/ok total to still be 2, got %v", got)
+	}
+	if got := counts[MetricRestarts][""]; got != 1 {
+		t.Fatalf("expected the compacted restarts total to still be 1, got %v", got)
+	}
+	if got := values[MetricRouteOps]["remove\x00error"]; len(got) != 2 || got[0] != "remove" || got[1] != "error" {
+		t.Fatalf("expected compacted label values to round-trip, got %v", got)
+	}
+
+	// a further Record call should append on top of the compacted totals,
+	// not reset them.
+	Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+	counts, _ = tally()
+	if got := counts[MetricRouteOps]["add\x00ok"]; got != 3 {
+		t.Fatalf("expected a Record after compaction to add to the compacted total, got %v", got)
+	}
+}
+
+func TestRecordCompactsWhenPastSizeThreshold(t *testing.T) {
+	Dir = t.TempDir()
+	path := filepath.Join(Dir, eventsFile)
+
+	for i := 0; i < 50; i++ {
+		Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+	}
+	before := countLines(t, path)
+
+	if err := os.Truncate(path, maxEventsFileSize); err != nil {
+		t.Fatalf("grow events file: %v", err)
+	}
+	Record(MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+
+	after := countLines(t, path)
+	if after >= before {
+		t.Fatalf("expected Record to compact the log once it passed maxEventsFileSize, got %d lines (before growth: %d)", after, before)
+	}
+
+	counts, _ := tally()
+	if got := counts[MetricRouteOps]["add\x00ok"]; got != 51 {
+		t.Fatalf("expected compaction to preserve every prior increment, got %v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var psCommand = cli.Command{
+	Name:  "ps",
+	Usage: "list containers and their current health",
+	Action: func(clix *cli.Context) error {
+		ctx := cfg.Context()
+		containers, err := cfg.Client().Containers(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 1, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tSERVICE\tHEALTH")
+		for _, container := range containers {
+			id := container.ID()
+			status := "stopped"
+			if task, err := container.Task(ctx, nil); err == nil {
+				s, err := task.Status(ctx)
+				if err == nil {
+					status = string(s.Status)
+				}
+			}
+			c, err := cfg.ContainerConfig(id)
+			if err != nil || len(c.Services) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\n", id, status)
+				continue
+			}
+			// Health is set by a goroutine running inside this task's own
+			// shim process, so it can't be read back out of this process's
+			// memory; go through the Register backend instead, the same
+			// cross-process channel `boss diagnostics` uses.
+			health := map[string]string{}
+			if reg := cfg.GetRegister(); reg != nil {
+				checks, err := reg.Checks(id)
+				if err != nil {
+					logrus.WithError(err).WithField("id", id).Debug("get service checks")
+				}
+				for _, chk := range checks {
+					health[chk.Name] = chk.Status
+				}
+			}
+			for name := range c.Services {
+				state, ok := health[name]
+				if !ok {
+					state = "unknown"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id, status, name, state)
+			}
+		}
+		return w.Flush()
+	},
+}
@@ -1,48 +1,169 @@
+// Package route sets up per-task networking through a CNI plugin chain
+// instead of shelling out to `ip link add ... type macvlan`. A conflist
+// under /etc/boss/net.d describes the chain (macvlan by default, but
+// bridge, ipvlan, host-local IPAM, and portmap all work the same way k3s
+// configures plugins.cri.cni), and Create/Add/Remove drive it against the
+// task's network namespace via libcni, the same as any other CNI-based
+// runtime.
 package route
 
 import (
-	"net"
-	"os/exec"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/containernetworking/cni/libcni"
+	current "github.com/containernetworking/cni/pkg/types/current"
 	"github.com/pkg/errors"
+
+	"github.com/crosbymichael/boss/bosserrdefs"
+	"github.com/crosbymichael/boss/metrics"
 )
 
-const Interface = "mvlan0"
+// DefaultConfDir is where boss looks for CNI conflists, mirroring the
+// /etc/cni/net.d convention most CNI-driven runtimes use.
+const DefaultConfDir = "/etc/boss/net.d"
 
-func Create(iface, address string) (err error) {
-	// don't create if it already exists
-	if _, err := net.InterfaceByName(Interface); err == nil {
-		return nil
-	}
-	defer func() {
-		if err != nil {
-			ip("link", "del", Interface)
-		}
-	}()
-	if err := ip("link", "add", "link", iface, Interface, "type", "macvlan", "mode", "bridge"); err != nil {
-		return err
+// DefaultBinDir is where the CNI plugin binaries (macvlan, bridge, ipvlan,
+// host-local, portmap, ...) are expected to live.
+const DefaultBinDir = "/opt/cni/bin"
+
+// IfName is the interface name created inside the task's network
+// namespace.
+const IfName = "eth0"
+
+// DefaultMacvlanConflist is installed as macvlan.conflist when confDir has
+// no conflist yet, keeping macvlan-bridge-mode as boss's out of the box
+// network even though any CNI plugin chain (bridge, ipvlan, ...) works.
+const DefaultMacvlanConflist = `{
+  "cniVersion": "0.4.0",
+  "name": "macvlan",
+  "plugins": [
+    {
+      "type": "macvlan",
+      "mode": "bridge",
+      "ipam": {
+        "type": "host-local"
+      }
+    },
+    {
+      "type": "portmap",
+      "capabilities": { "portMappings": true }
+    }
+  ]
+}
+`
+
+// EnsureDefaultConflist writes DefaultMacvlanConflist into confDir as
+// macvlan.conflist if the directory has no conflist for "macvlan" yet.
+func EnsureDefaultConflist(confDir string) error {
+	if confDir == "" {
+		confDir = DefaultConfDir
 	}
-	if err := ip("address", "add", address, "dev", Interface); err != nil {
-		return err
+	path := filepath.Join(confDir, "macvlan.conflist")
+	if _, err := os.Stat(path); err == nil {
+		return nil
 	}
-	if err := ip("link", "set", "dev", Interface, "up"); err != nil {
+	if err := os.MkdirAll(confDir, 0755); err != nil {
 		return err
 	}
-	return ip("route", "flush", "dev", Interface)
+	return ioutil.WriteFile(path, []byte(DefaultMacvlanConflist), 0644)
 }
 
-func Add(address string) error {
-	return ip("route", "add", address, "dev", Interface, "metric", "0")
+// Network drives a single named CNI plugin chain, loaded from
+// <confDir>/<name>.conflist, against task network namespaces.
+type Network struct {
+	name    string
+	cni     *libcni.CNIConfig
+	netlist *libcni.NetworkConfigList
 }
 
-func Remove(address string) error {
-	return ip("route", "del", address, "dev", Interface)
+// New loads the conflist for name out of confDir and returns a Network
+// ready to add/remove tasks from it.
+func New(name, confDir, binDir string) (*Network, error) {
+	if confDir == "" {
+		confDir = DefaultConfDir
+	}
+	if binDir == "" {
+		binDir = DefaultBinDir
+	}
+	path := filepath.Join(confDir, name+".conflist")
+	netlist, err := libcni.ConfListFromFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, bosserrdefs.NotFound(errors.Wrapf(err, "load cni conflist %s", path))
+		}
+		return nil, bosserrdefs.System(errors.Wrapf(err, "load cni conflist %s", path))
+	}
+	return &Network{
+		name:    name,
+		cni:     libcni.NewCNIConfig([]string{binDir}, nil),
+		netlist: netlist,
+	}, nil
 }
 
-func ip(args ...string) error {
-	out, err := exec.Command("ip", args...).CombinedOutput()
+// Create runs the ADD chain against the task's network namespace,
+// returning the CNI result (IPs, routes, DNS) in place of the raw IP
+// string the old shell-out implementation produced.
+func (n *Network) Create(id string, pid int) (*current.Result, error) {
+	rt := n.runtimeConf(id, pid)
+	result, err := n.cni.AddNetworkList(context.Background(), n.netlist, rt)
 	if err != nil {
-		return errors.Wrap(err, string(out))
+		metrics.Record(metrics.MetricRouteOps, map[string]string{"op": "add", "result": "error"})
+		return nil, wrapCNIError(err, "cni add")
 	}
+	metrics.Record(metrics.MetricRouteOps, map[string]string{"op": "add", "result": "ok"})
+	return current.NewResultFromResult(result)
+}
+
+// Remove runs the DEL chain for the task, releasing its IPAM allocation
+// and tearing down its side of the plugin chain (veth, macvlan slave, ...).
+func (n *Network) Remove(id string, pid int) error {
+	rt := n.runtimeConf(id, pid)
+	err := n.cni.DelNetworkList(context.Background(), n.netlist, rt)
+	if err != nil {
+		metrics.Record(metrics.MetricRouteOps, map[string]string{"op": "remove", "result": "error"})
+		return wrapCNIError(err, "cni del")
+	}
+	metrics.Record(metrics.MetricRouteOps, map[string]string{"op": "remove", "result": "ok"})
 	return nil
 }
+
+// Add re-runs the chain's CHECK, confirming the task's interface and
+// routes still match what CNI set up at Create.
+func (n *Network) Add(id string, pid int) error {
+	rt := n.runtimeConf(id, pid)
+	return n.cni.CheckNetworkList(context.Background(), n.netlist, rt)
+}
+
+// wrapCNIError classifies a CNI plugin failure the same way the old
+// shell-out `ip` calls used to distinguish "already exists" from a
+// transient failure, except now it's keyed off bosserrdefs instead of
+// string-matching at every call site.
+func wrapCNIError(err error, msg string) error {
+	wrapped := errors.Wrap(err, msg)
+	if strings.Contains(err.Error(), "exists") {
+		return bosserrdefs.Conflict(wrapped)
+	}
+	return bosserrdefs.Unavailable(wrapped)
+}
+
+func (n *Network) runtimeConf(id string, pid int) *libcni.RuntimeConf {
+	return &libcni.RuntimeConf{
+		ContainerID: id,
+		NetNS:       fmt.Sprintf("/proc/%d/ns/net", pid),
+		IfName:      IfName,
+	}
+}
+
+// IP returns the first IPv4/IPv6 address CNI assigned to the task, for
+// callers that only care about a single address (service registration).
+func IP(result *current.Result) string {
+	if result == nil || len(result.IPs) == 0 {
+		return ""
+	}
+	return result.IPs[0].Address.IP.String()
+}
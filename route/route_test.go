@@ -0,0 +1,35 @@
+package route
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/crosbymichael/boss/bosserrdefs"
+)
+
+func TestWrapCNIErrorClassifiesExistsAsConflict(t *testing.T) {
+	err := wrapCNIError(errors.New("interface eth0 already exists"), "cni add")
+	if !bosserrdefs.IsConflict(err) {
+		t.Fatalf("expected an \"already exists\" CNI failure to be classified as a conflict, got %v", err)
+	}
+	if bosserrdefs.IsUnavailable(err) {
+		t.Fatalf("expected a conflict not to also be classified as unavailable")
+	}
+}
+
+func TestWrapCNIErrorClassifiesOtherFailuresAsUnavailable(t *testing.T) {
+	err := wrapCNIError(errors.New("plugin macvlan not found in path"), "cni add")
+	if !bosserrdefs.IsUnavailable(err) {
+		t.Fatalf("expected a non-\"exists\" CNI failure to be classified as unavailable, got %v", err)
+	}
+	if bosserrdefs.IsConflict(err) {
+		t.Fatalf("expected an unavailable error not to also be classified as a conflict")
+	}
+}
+
+func TestWrapCNIErrorWrapsMessage(t *testing.T) {
+	err := wrapCNIError(errors.New("boom"), "cni del")
+	if got := err.Error(); got == "" {
+		t.Fatalf("expected a non-empty wrapped error message")
+	}
+}
@@ -0,0 +1,278 @@
+// Package shim implements a containerd shim v2 (io.containerd.boss.v1) that
+// replaces the old systemd exec-start-* proxy. Instead of running as a child
+// of a systemd unit and reconnecting to containerd over a client connection,
+// boss is now loaded by containerd directly and speaks the task API, the
+// same way runc.v2 and inclavare's rune.v2 do.
+//
+// The shim embeds the upstream runc v2 task service and only overrides the
+// lifecycle points boss needs: Create writes resolv.conf, sets up the
+// macvlan interface and apparmor profile, and registers the task's
+// services, and Delete/exit handling tears the network down and flips
+// maintenance mode the way the systemd exec-start-post hook used to.
+package shim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/containerd/containerd/contrib/apparmor"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/containerd/containerd/runtime/v2/shim"
+	taskAPI "github.com/containerd/containerd/runtime/v2/task"
+	"github.com/containerd/typeurl"
+	current "github.com/containernetworking/cni/pkg/types/current"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/crosbymichael/boss/bosserrdefs"
+	"github.com/crosbymichael/boss/config"
+	"github.com/crosbymichael/boss/health"
+	"github.com/crosbymichael/boss/route"
+)
+
+// defaultRetries bounds how many attempts createNetwork makes at a CNI add
+// that keeps failing with a bosserrdefs.ErrUnavailable error before giving
+// up, replacing the old 20-attempt client.Reconnect() loop with a
+// typed-error retry.
+const defaultRetries = 5
+
+// RuntimeName is the shim's runtime id, set as the container's runtime in
+// containerd so it gets loaded directly instead of going through runc.v2.
+const RuntimeName = "io.containerd.boss.v1"
+
+// New constructs the boss task service for the given task id. It is passed
+// to shim.Run as the shim's manager/service factory.
+func New(ctx context.Context, id string, publisher shim.Publisher, shutdown func()) (shim.Shim, error) {
+	runcService, err := runc.New(ctx, id, publisher, shutdown)
+	if err != nil {
+		return nil, err
+	}
+	return &service{
+		TaskService: runcService,
+		cfg:         config.Default(),
+	}, nil
+}
+
+// service wraps the upstream runc v2 task service, adding boss's networking,
+// apparmor, and service-registration responsibilities around task create and
+// delete.
+type service struct {
+	taskAPI.TaskService
+	cfg *config.Config
+
+	container    *config.Container
+	pid          int
+	cancelHealth context.CancelFunc
+	execSeq      uint64
+}
+
+// Create writes boss's managed resolv.conf and sets up the apparmor
+// profile for the task before handing off to runc, then sets up the
+// macvlan interface and registers any configured services once an IP has
+// been assigned.
+func (s *service) Create(ctx context.Context, r *taskAPI.CreateTaskRequest) (*taskAPI.CreateTaskResponse, error) {
+	if err := applyAppArmorProfile(r.Bundle); err != nil {
+		return nil, err
+	}
+	if err := config.WriteResolvConf(s.cfg.Nameservers); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.TaskService.Create(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	s.pid = int(resp.Pid)
+
+	c, err := s.cfg.ContainerConfig(r.ID)
+	if err != nil {
+		// the container has no boss-managed network/services; nothing left to do
+		return resp, nil
+	}
+	result, err := s.createNetwork(c.Network, r.ID, int(resp.Pid))
+	if err != nil {
+		s.TaskService.Delete(ctx, &taskAPI.DeleteRequest{ID: r.ID})
+		return nil, err
+	}
+	if ip := route.IP(result); ip != "" {
+		if reg := s.cfg.GetRegister(); reg != nil {
+			for name, srv := range c.Services {
+				if err := reg.Register(r.ID, name, ip, srv); err != nil {
+					logrus.WithError(err).Error("register service")
+				}
+			}
+		}
+	}
+	s.container = c
+	return resp, nil
+}
+
+// createNetwork drives the CNI chain for a task, retrying a transient
+// (bosserrdefs.ErrUnavailable) failure and tolerating a conflict (the
+// interface/route was already set up, e.g. across a shim restart).
+func (s *service) createNetwork(network, id string, pid int) (*current.Result, error) {
+	var (
+		result *current.Result
+		err    error
+	)
+	for i := 0; i < defaultRetries; i++ {
+		result, err = s.cfg.Network(network).Create(id, pid)
+		if err == nil {
+			return result, nil
+		}
+		if bosserrdefs.IsConflict(err) {
+			return nil, nil
+		}
+		if !bosserrdefs.IsUnavailable(err) {
+			return nil, err
+		}
+		logrus.WithError(err).WithField("id", id).Debug("retrying cni add")
+	}
+	return nil, err
+}
+
+// Start flips the task's services out of maintenance mode once the task is
+// actually running, mirroring what the systemd exec-start loop used to do
+// after task.Start succeeded.
+func (s *service) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.StartResponse, error) {
+	resp, err := s.TaskService.Start(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if reg := s.cfg.GetRegister(); reg != nil {
+		if err := reg.DisableMaintainance(r.ID); err != nil {
+			logrus.WithError(err).Error("disable service maintenance")
+		}
+	}
+	s.startHealthChecks(r.ID)
+	return resp, nil
+}
+
+// startHealthChecks launches one health.Run goroutine per service that
+// declares a HealthCheck, replacing the old once-on-exit maintenance flip
+// with a continuous probe for the life of the task.
+func (s *service) startHealthChecks(id string) {
+	if s.container == nil {
+		return
+	}
+	reg := s.cfg.GetRegister()
+	if reg == nil {
+		return
+	}
+	hctx, cancel := context.WithCancel(context.Background())
+	s.cancelHealth = cancel
+	exec := func(ctx context.Context, cmd []string) error {
+		return s.execInTask(ctx, id, cmd)
+	}
+	for name, srv := range s.container.Services {
+		if srv.Health == nil {
+			continue
+		}
+		go health.Run(hctx, reg, id, name, srv.Health, exec)
+	}
+}
+
+// execInTask runs cmd as a new process inside the task's own namespaces,
+// using the embedded runc service's Exec/Start/Wait/Delete the same way
+// containerd's own client drives `ctr task exec`. This is what lets a
+// Command healthcheck see the container's filesystem and network instead
+// of the shim's.
+func (s *service) execInTask(ctx context.Context, id string, cmd []string) error {
+	execID := fmt.Sprintf("health-%d", atomic.AddUint64(&s.execSeq, 1))
+	spec, err := typeurl.MarshalAny(&specs.Process{
+		Cwd:  "/",
+		Args: cmd,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.TaskService.Exec(ctx, &taskAPI.ExecProcessRequest{
+		ID:     id,
+		ExecID: execID,
+		Stdout: os.DevNull,
+		Stderr: os.DevNull,
+		Spec:   spec,
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := s.TaskService.Delete(ctx, &taskAPI.DeleteRequest{ID: id, ExecID: execID}); err != nil {
+			logrus.WithError(err).WithField("id", id).Debug("delete healthcheck exec process")
+		}
+	}()
+	if _, err := s.TaskService.Start(ctx, &taskAPI.StartRequest{ID: id, ExecID: execID}); err != nil {
+		return err
+	}
+	wr, err := s.TaskService.Wait(ctx, &taskAPI.WaitRequest{ID: id, ExecID: execID})
+	if err != nil {
+		return err
+	}
+	if wr.ExitStatus != 0 {
+		return fmt.Errorf("healthcheck command exited %d", wr.ExitStatus)
+	}
+	return nil
+}
+
+// Delete tears down the task's network and flips its services into
+// maintenance mode, replacing the old systemd exec-start-post hook.
+func (s *service) Delete(ctx context.Context, r *taskAPI.DeleteRequest) (*taskAPI.DeleteResponse, error) {
+	resp, err := s.TaskService.Delete(ctx, r)
+	if s.cancelHealth != nil {
+		s.cancelHealth()
+	}
+	if s.container != nil {
+		if merr := s.cfg.Network(s.container.Network).Remove(r.ID, s.pid); merr != nil {
+			logrus.WithError(merr).Error("remove cni network")
+		}
+	}
+	if reg := s.cfg.GetRegister(); reg != nil {
+		if merr := reg.EnableMaintainance(r.ID, "task exited"); merr != nil {
+			logrus.WithError(merr).Error("enable service maintenance")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Kill forwards straight to the embedded runc service. There's no longer a
+// network boundary to retry across the way trySendSignal used to retry
+// client.Reconnect() failures: Kill calls directly into the runc task
+// service in the same process, which returns plain runc/syscall errors,
+// not a bosserrdefs-classified one.
+func (s *service) Kill(ctx context.Context, r *taskAPI.KillRequest) (*taskAPI.Empty, error) {
+	return s.TaskService.Kill(ctx, r)
+}
+
+// applyAppArmorProfile rewrites the bundle's config.json with the boss
+// apparmor profile set on the process, the way runc itself applies
+// oci.SpecOpts before create. r.Options doesn't carry the OCI spec, so the
+// profile has to be set on disk before handing the bundle to runc.
+func applyAppArmorProfile(bundle string) error {
+	path := filepath.Join(bundle, "config.json")
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var spec specs.Spec
+	if err := json.NewDecoder(f).Decode(&spec); err != nil {
+		return err
+	}
+	if err := apparmor.WithDefaultProfile("boss")(context.Background(), nil, nil, &spec); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	return json.NewEncoder(f).Encode(&spec)
+}